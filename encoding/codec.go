@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"io"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Decoder{
+		"json":    JSONDecoder,
+		"msgpack": msgpackDecoder,
+		"cbor":    cborDecoder,
+	}
+	contentTypes = map[string]string{
+		"application/json":      "json",
+		"application/msgpack":   "msgpack",
+		"application/x-msgpack": "msgpack",
+		"application/cbor":      "cbor",
+	}
+)
+
+// RegisterDecoder associates name with dec in the package-wide decoder
+// registry. It is how callers plug in formats this package does not know
+// about out of the box, such as a protobuf-with-descriptor Decoder built
+// from a backend's own .proto definitions.
+func RegisterDecoder(name string, dec Decoder) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[name] = dec
+}
+
+// RegisterContentType maps the media type of an incoming Content-Type
+// header to the name of a Decoder registered with RegisterDecoder.
+func RegisterContentType(contentType, name string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	contentTypes[strings.ToLower(contentType)] = name
+}
+
+// GetDecoder looks up the Decoder registered under name.
+func GetDecoder(name string) (Decoder, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	dec, ok := codecs[name]
+	return dec, ok
+}
+
+// DecoderForContentType resolves the Decoder registered for the media type
+// carried by an upstream Content-Type header, ignoring parameters such as
+// charset.
+func DecoderForContentType(contentType string) (Decoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+
+	codecsMu.RLock()
+	name, ok := contentTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+	codecsMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return GetDecoder(name)
+}
+
+func msgpackDecoder(r io.Reader, v *map[string]interface{}) error {
+	return msgpack.NewDecoder(r).Decode(v)
+}
+
+func cborDecoder(r io.Reader, v *map[string]interface{}) error {
+	return cbor.NewDecoder(r).Decode(v)
+}
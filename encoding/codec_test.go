@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import "testing"
+
+func TestDecoderForContentType(t *testing.T) {
+	cases := []struct {
+		contentType string
+		wantOK      bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"application/msgpack", true},
+		{"application/cbor", true},
+		{"application/x-unknown", false},
+	}
+
+	for _, c := range cases {
+		_, ok := DecoderForContentType(c.contentType)
+		if ok != c.wantOK {
+			t.Errorf("DecoderForContentType(%q) ok = %v, want %v", c.contentType, ok, c.wantOK)
+		}
+	}
+}
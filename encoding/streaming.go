@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// StreamingDecoder decodes the top level fields of r one at a time instead
+// of buffering the whole document into a map[string]interface{}, invoking
+// visit for every field whose key is present in wanted (or every field, if
+// wanted is empty). Implementations are free to skip the raw bytes backing
+// an unwanted field without allocating it.
+type StreamingDecoder interface {
+	DecodeFields(r io.Reader, wanted map[string]bool, visit func(key string, value interface{}) error) error
+}
+
+// JSONStreamingDecoder is the StreamingDecoder for the json wire format. It
+// walks the top level object with encoding/json.Decoder's token API so
+// fields outside of wanted are discarded via Decoder.Decode(&json.RawMessage
+// stand-in) without ever being unmarshalled into a Go value.
+type JSONStreamingDecoder struct{}
+
+// DecodeFields implements the StreamingDecoder interface.
+func (JSONStreamingDecoder) DecodeFields(r io.Reader, wanted map[string]bool, visit func(key string, value interface{}) error) error {
+	dec := json.NewDecoder(r)
+
+	if _, err := dec.Token(); err != nil { // consume the opening '{'
+		return err
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		if len(wanted) > 0 && !wanted[key] {
+			var discard json.RawMessage
+			if err := dec.Decode(&discard); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		if err := visit(key, value); err != nil {
+			return err
+		}
+	}
+
+	_, err := dec.Token() // consume the closing '}'
+	return err
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeJSONFixture builds a payload with n top level fields, each holding a
+// sizable nested object, so a projection down to a couple of fields has a
+// large unwanted subtree to skip.
+func largeJSONFixture(n int) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `"field_%d":{"a":1,"b":"x","c":[1,2,3,4,5],"d":{"e":"f"}}`, i)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func BenchmarkJSONStreamingDecoder_Projected(b *testing.B) {
+	body := largeJSONFixture(500)
+	wanted := map[string]bool{"field_0": true, "field_1": true}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data := make(map[string]interface{}, 2)
+		err := JSONStreamingDecoder{}.DecodeFields(strings.NewReader(body), wanted, func(key string, value interface{}) error {
+			data[key] = value
+			return nil
+		})
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkJSONDecodeFull(b *testing.B) {
+	body := largeJSONFixture(500)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var data map[string]interface{}
+		if err := json.NewDecoder(bytes.NewReader([]byte(body))).Decode(&data); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
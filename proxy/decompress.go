@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decompressor builds a io.ReadCloser able to undo a single Content-Encoding
+// token (e.g. "gzip", "br") around the given reader.
+type Decompressor func(io.Reader) (io.ReadCloser, error)
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[string]Decompressor{
+		"gzip":    gzipDecompressor,
+		"x-gzip":  gzipDecompressor,
+		"br":      brotliDecompressor,
+		"zstd":    zstdDecompressor,
+		"deflate": deflateDecompressor,
+	}
+)
+
+// RegisterDecompressor associates a Content-Encoding token with the
+// Decompressor in charge of undoing it. Registering a token that is
+// already known overwrites the previous entry, so callers can replace
+// the built-in gzip, br, zstd and deflate handling if required.
+func RegisterDecompressor(name string, fn Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[strings.ToLower(name)] = fn
+}
+
+// SupportedEncodings returns the comma separated list of the Content-Encoding
+// tokens this process knows how to decode, suitable for use as the value of
+// an outgoing Accept-Encoding header.
+func SupportedEncodings() string {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	names := make([]string, 0, len(decompressors))
+	for name := range decompressors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// decompress wraps r with the decompressors required to undo the value of a
+// Content-Encoding header, applying them in reverse order as mandated by
+// RFC 7231#section-3.1.2.2 (the last listed encoding is the first applied
+// and so must be the first undone). The returned ReadCloser's Close closes
+// every intermediate decompressor it opened along the way, not just the
+// outermost one — zstd in particular needs its Close called to stop the
+// background goroutines its Decoder spawns, and that holds regardless of
+// where in the chain zstd appears.
+func decompress(contentEncoding string, r io.ReadCloser) (io.ReadCloser, error) {
+	tokens := strings.Split(contentEncoding, ",")
+
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	var closers []io.Closer
+	cur := io.Reader(r)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		name := strings.ToLower(strings.TrimSpace(tokens[i]))
+		if name == "" || name == "identity" {
+			continue
+		}
+
+		fn, ok := decompressors[name]
+		if !ok {
+			closeAll(closers)
+			return nil, fmt.Errorf("proxy: unsupported Content-Encoding %q", name)
+		}
+
+		next, err := fn(cur)
+		if err != nil {
+			closeAll(closers)
+			return nil, err
+		}
+		closers = append(closers, next)
+		cur = next
+	}
+
+	if len(closers) == 0 {
+		return r, nil
+	}
+	return &chainedReadCloser{Reader: cur, closers: closers}, nil
+}
+
+func closeAll(closers []io.Closer) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Close()
+	}
+}
+
+// chainedReadCloser reads from the outermost decompressor in a multi-token
+// Content-Encoding chain while closing every decompressor opened along the
+// way, outermost first.
+type chainedReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (c *chainedReadCloser) Close() error {
+	var firstErr error
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func gzipDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func brotliDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(brotli.NewReader(r)), nil
+}
+
+func zstdDecompressor(r io.Reader) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
+
+func deflateDecompressor(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+// AcceptEncodingRoundTripper wraps Next so every outgoing request
+// advertises, via its Accept-Encoding header, every Content-Encoding
+// RegisterDecompressor knows this process can decode. Requests that
+// already carry an explicit Accept-Encoding are left untouched.
+//
+// Nothing in this package constructs it automatically: this tree does not
+// include the backend http.Client/Transport construction code (that lives
+// in the transport/http/client package), so there is no config-driven
+// default wiring here. Callers building a backend's RoundTripper chain
+// from config.Backend need to compose AcceptEncodingRoundTripper{Next: ...}
+// themselves to have the gateway actually advertise what
+// DefaultHTTPResponseParserFactory is able to undo upstream.
+type AcceptEncodingRoundTripper struct {
+	Next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t AcceptEncodingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", SupportedEncodings())
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompress_MultiTokenReverseOrder(t *testing.T) {
+	want := "the quick brown fox"
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	if _, err := gz.Write([]byte(want)); err != nil {
+		t.Fatalf("unexpected error gzipping fixture: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("unexpected error closing gzip writer: %v", err)
+	}
+
+	// "gzip, identity" must be undone right to left: identity first (a
+	// no-op), then gzip.
+	r, err := decompress("gzip, identity", io.NopCloser(&gzipped))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading decompressed body: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecompress_UnknownToken(t *testing.T) {
+	if _, err := decompress("bzip2", io.NopCloser(bytes.NewReader(nil))); err == nil {
+		t.Fatal("expected an error for an unregistered Content-Encoding token")
+	}
+}
+
+type countingCloser struct {
+	io.Reader
+	closed *int
+}
+
+func (c countingCloser) Close() error {
+	*c.closed++
+	return nil
+}
+
+func TestDecompress_ClosesEveryIntermediateReader(t *testing.T) {
+	var innerCloses, outerCloses int
+	RegisterDecompressor("trackclose-inner", func(r io.Reader) (io.ReadCloser, error) {
+		return countingCloser{Reader: r, closed: &innerCloses}, nil
+	})
+	RegisterDecompressor("trackclose-outer", func(r io.Reader) (io.ReadCloser, error) {
+		return countingCloser{Reader: r, closed: &outerCloses}, nil
+	})
+
+	// "trackclose-outer, trackclose-inner" is undone right to left:
+	// trackclose-inner first (an intermediate reader, never returned to
+	// the caller directly), then trackclose-outer (the outermost reader,
+	// the one actually returned). Before this fix only the returned
+	// reader's Close ran, leaking every reader undone before it.
+	r, err := decompress("trackclose-outer, trackclose-inner", io.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("unexpected error reading decompressed body: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	if innerCloses != 1 {
+		t.Errorf("got %d closes on the intermediate reader, want 1", innerCloses)
+	}
+	if outerCloses != 1 {
+		t.Errorf("got %d closes on the outer reader, want 1", outerCloses)
+	}
+}
+
+func TestAcceptEncodingRoundTripper_SetsHeaderWhenAbsent(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Accept-Encoding")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: AcceptEncodingRoundTripper{}}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got == "" {
+		t.Fatal("expected Accept-Encoding to be set")
+	}
+	if got != SupportedEncodings() {
+		t.Errorf("got %q, want %q", got, SupportedEncodings())
+	}
+}
+
+func TestAcceptEncodingRoundTripper_LeavesExplicitHeaderAlone(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Accept-Encoding")
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: AcceptEncodingRoundTripper{}}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != "identity" {
+		t.Errorf("got %q, want %q", got, "identity")
+	}
+}
@@ -3,12 +3,13 @@
 package proxy
 
 import (
-	"compress/gzip"
 	"context"
 	"github.com/luraproject/lura/v2/config"
 	"io"
+	"mime"
 	"net/http"
 	"net/textproto"
+	"strings"
 
 	"github.com/luraproject/lura/v2/encoding"
 )
@@ -18,14 +19,44 @@ type HTTPResponseParser func(context.Context, *http.Response) (*Response, error)
 
 // DefaultHTTPResponseParserConfig defines a default HTTPResponseParserConfig
 var DefaultHTTPResponseParserConfig = HTTPResponseParserConfig{
-	func(_ io.Reader, _ *map[string]interface{}) error { return nil },
-	EntityFormatterFunc(func(r Response) Response { return r }),
+	Decoder:         func(_ io.Reader, _ *map[string]interface{}) error { return nil },
+	EntityFormatter: EntityFormatterFunc(func(r Response) Response { return r }),
 }
 
 // HTTPResponseParserConfig contains the config for a given HttpResponseParser
 type HTTPResponseParserConfig struct {
 	Decoder         encoding.Decoder
 	EntityFormatter EntityFormatter
+
+	// ErrorResponseParser, when set, is consulted before decoding a
+	// successful response. If it returns a non-nil error for resp, that
+	// error is returned by the HTTPResponseParser together with a
+	// Response carrying the upstream status code, headers and error body.
+	ErrorResponseParser ErrorResponseParser
+
+	// ErrorBackendAlias is the key under which the error body captured by
+	// ErrorResponseParser is stored in the Data of the Response returned
+	// alongside the error, where downstream merge logic and plugins can
+	// reach it via TableGet.
+	ErrorBackendAlias string
+
+	// Streaming, when true, StreamingDecoder is set and ContentType is
+	// empty or json, makes the parser decode the backend body field by
+	// field instead of buffering it whole. When EntityFormatter implements
+	// ProjectionAware, fields it will not project are skipped. Large
+	// payloads with a small projection avoid allocating the unused part
+	// of the tree this way.
+	Streaming        bool
+	StreamingDecoder encoding.StreamingDecoder
+
+	// ContentType pins the wire format the backend answers with (e.g.
+	// "application/json", "application/msgpack", "application/cbor"),
+	// used to pick a Decoder from the encoding package's registry. Leave
+	// empty to keep using Decoder as-is; the parser never infers this
+	// from the upstream response's own Content-Type header, since doing
+	// so would silently swap out an explicitly configured Decoder for
+	// any backend that happens to answer with a registered content type.
+	ContentType string
 }
 
 // HTTPResponseParserFactory creates HTTPResponseParser from a given HTTPResponseParserConfig
@@ -36,17 +67,35 @@ func DefaultHTTPResponseParserFactory(remote *config.Backend, cfg HTTPResponsePa
 	return func(ctx context.Context, resp *http.Response) (*Response, error) {
 		defer resp.Body.Close()
 
-		var reader io.ReadCloser
-		switch resp.Header.Get("Content-Encoding") {
-		case "gzip":
-			reader, _ = gzip.NewReader(resp.Body)
+		if cfg.ErrorResponseParser != nil {
+			if err := cfg.ErrorResponseParser(ctx, resp); err != nil {
+				return errorResponse(cfg.ErrorBackendAlias, err), err
+			}
+		}
+
+		reader := io.ReadCloser(resp.Body)
+		if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" {
+			var err error
+			if reader, err = decompress(contentEncoding, resp.Body); err != nil {
+				return nil, err
+			}
 			defer reader.Close()
-		default:
-			reader = resp.Body
 		}
 
-		var data map[string]interface{}
-		if err := cfg.Decoder(reader, &data); err != nil {
+		contentType := effectiveContentType(cfg, resp)
+		decoder := decoderFor(cfg, contentType)
+
+		data := make(map[string]interface{})
+		if cfg.Streaming && cfg.StreamingDecoder != nil && isJSONContentType(contentType) {
+			wanted := projectedFields(cfg.EntityFormatter)
+			visit := func(key string, value interface{}) error {
+				data[key] = value
+				return nil
+			}
+			if err := cfg.StreamingDecoder.DecodeFields(reader, wanted, visit); err != nil {
+				return nil, err
+			}
+		} else if err := decoder(reader, &data); err != nil {
 			return nil, err
 		}
 
@@ -70,6 +119,43 @@ func DefaultHTTPResponseParserFactory(remote *config.Backend, cfg HTTPResponsePa
 	}
 }
 
+// effectiveContentType returns the wire format to decode resp as. It is
+// only ever cfg.ContentType: picking a registry codec is an explicit,
+// per-backend opt-in, never inferred from the upstream response's own
+// Content-Type header, so an endpoint that never touches this field keeps
+// using cfg.Decoder exactly as it did before the registry existed, no
+// matter what Content-Type the backend happens to answer with.
+func effectiveContentType(cfg HTTPResponseParserConfig, _ *http.Response) string {
+	return cfg.ContentType
+}
+
+// decoderFor resolves the encoding.Decoder to use for contentType, falling
+// back to cfg.Decoder when contentType is empty (the operator did not opt
+// in) or names no codec registered with the encoding package.
+func decoderFor(cfg HTTPResponseParserConfig, contentType string) encoding.Decoder {
+	if contentType == "" {
+		return cfg.Decoder
+	}
+	if dec, ok := encoding.DecoderForContentType(contentType); ok {
+		return dec
+	}
+	return cfg.Decoder
+}
+
+// isJSONContentType reports whether contentType names the json media type,
+// ignoring parameters such as charset. An empty contentType is treated as
+// json, matching the package's json-by-default history.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/json")
+}
+
 // NoOpHTTPResponseParser is a HTTPResponseParser implementation that just copies the
 // http response body into the proxy response IO
 func NoOpHTTPResponseParser(ctx context.Context, resp *http.Response) (*Response, error) {
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+
+	"github.com/luraproject/lura/v2/encoding"
+)
+
+// StatusCoder is implemented by errors that know the upstream HTTP status
+// code that produced them, so callers do not need to type-assert down to
+// HTTPResponseError to react to it.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// HTTPResponseError is returned by an ErrorResponseParser when a backend
+// answers with a status code flagged as an error. It implements error and
+// StatusCoder, and keeps the decoded body available so the gateway does
+// not have to drop it on failure paths.
+type HTTPResponseError struct {
+	Code    int
+	Msg     string
+	Enc     string
+	Headers http.Header
+	Body    map[string]interface{}
+
+	// RawBody holds the backend's response body verbatim whenever decoder
+	// fails to parse it (e.g. a plain-text or HTML error page from a
+	// backend configured with a JSON decoder). Body is left nil in that
+	// case; callers that need the error detail at all still have it here
+	// instead of it being dropped silently.
+	RawBody []byte
+}
+
+// Error implements the error interface.
+func (h HTTPResponseError) Error() string {
+	return h.Msg
+}
+
+// StatusCode implements the StatusCoder interface.
+func (h HTTPResponseError) StatusCode() int {
+	return h.Code
+}
+
+// Encoding returns the Content-Encoding the body was decoded from.
+func (h HTTPResponseError) Encoding() string {
+	return h.Enc
+}
+
+// ErrorResponseParser builds the error to return for a backend response
+// flagged as an error. It returns nil when resp should be treated as a
+// regular, successful response.
+type ErrorResponseParser func(ctx context.Context, resp *http.Response) error
+
+// NewErrorResponseParser returns an ErrorResponseParser that treats any
+// response whose status code is in statusCodes as an error, decoding its
+// body (honoring Content-Encoding) with decoder and reporting headers
+// listed in headersFromResponse on the resulting HTTPResponseError.
+func NewErrorResponseParser(statusCodes map[int]struct{}, decoder encoding.Decoder, headersFromResponse []string) ErrorResponseParser {
+	return func(_ context.Context, resp *http.Response) error {
+		if _, ok := statusCodes[resp.StatusCode]; !ok {
+			return nil
+		}
+
+		reader := io.ReadCloser(resp.Body)
+		if contentEncoding := resp.Header.Get("Content-Encoding"); contentEncoding != "" {
+			var err error
+			if reader, err = decompress(contentEncoding, resp.Body); err != nil {
+				return HTTPResponseError{
+					Code:    resp.StatusCode,
+					Msg:     fmt.Sprintf("backend error (%s): %s", resp.Status, err.Error()),
+					Headers: headersFrom(resp, headersFromResponse),
+				}
+			}
+			defer reader.Close()
+		}
+
+		raw, err := io.ReadAll(reader)
+		if err != nil {
+			return HTTPResponseError{
+				Code:    resp.StatusCode,
+				Msg:     fmt.Sprintf("backend error (%s): %s", resp.Status, err.Error()),
+				Headers: headersFrom(resp, headersFromResponse),
+			}
+		}
+
+		var body map[string]interface{}
+		if err := decoder(bytes.NewReader(raw), &body); err != nil {
+			return HTTPResponseError{
+				Code:    resp.StatusCode,
+				Msg:     fmt.Sprintf("backend error (%s): could not decode body: %s", resp.Status, err.Error()),
+				Enc:     resp.Header.Get("Content-Encoding"),
+				Headers: headersFrom(resp, headersFromResponse),
+				RawBody: raw,
+			}
+		}
+
+		return HTTPResponseError{
+			Code:    resp.StatusCode,
+			Msg:     fmt.Sprintf("backend error (%s)", resp.Status),
+			Enc:     resp.Header.Get("Content-Encoding"),
+			Headers: headersFrom(resp, headersFromResponse),
+			Body:    body,
+		}
+	}
+}
+
+func headersFrom(resp *http.Response, headersFromResponse []string) http.Header {
+	h := make(http.Header)
+	for i := range headersFromResponse {
+		name := textproto.CanonicalMIMEHeaderKey(headersFromResponse[i])
+		if v := resp.Header.Get(name); v != "" {
+			h.Set(name, v)
+		}
+	}
+	return h
+}
+
+// TableGet looks up alias in data and, if present and itself a map (as left
+// behind by an ErrorResponseParser under the configured error_backend_alias
+// key), returns it. It is the accessor plugins such as the Lua or Martian
+// integrations use to reach the captured backend error body from
+// Response.Data without having to know its concrete shape up front.
+func TableGet(data map[string]interface{}, alias string) (map[string]interface{}, bool) {
+	raw, ok := data[alias]
+	if !ok {
+		return nil, false
+	}
+	table, ok := raw.(map[string]interface{})
+	return table, ok
+}
+
+// errorResponse builds the Response to surface alongside a non-nil error
+// returned by an ErrorResponseParser, instead of simply dropping the
+// decoded body. When err is an HTTPResponseError and alias is set, its Body
+// is stashed under alias so callers can pull it back out with
+// TableGet(resp.Data, alias); when the body could not be decoded, RawBody
+// is stashed under alias's "raw" key instead so the error detail is still
+// reachable rather than vanishing. An empty alias still yields a Response
+// carrying the upstream status code and headers; an err that is not an
+// HTTPResponseError yields nil.
+func errorResponse(alias string, err error) *Response {
+	hre, ok := err.(HTTPResponseError)
+	if !ok {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	if alias != "" {
+		if hre.Body != nil {
+			data[alias] = hre.Body
+		} else if hre.RawBody != nil {
+			data[alias] = map[string]interface{}{"raw": string(hre.RawBody)}
+		}
+	}
+
+	return &Response{
+		Data:       data,
+		IsComplete: false,
+		Metadata: Metadata{
+			StatusCode: hre.Code,
+			Headers:    hre.Headers,
+		},
+	}
+}
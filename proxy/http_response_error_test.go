@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func jsonDecoder(r io.Reader, v *map[string]interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func TestDefaultHTTPResponseParserFactory_ErrorBackendAlias(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Status:     "404 Not Found",
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"reason":"no such user"}`)),
+	}
+
+	cfg := HTTPResponseParserConfig{
+		Decoder:             jsonDecoder,
+		EntityFormatter:     EntityFormatterFunc(func(r Response) Response { return r }),
+		ErrorResponseParser: NewErrorResponseParser(map[int]struct{}{http.StatusNotFound: {}}, jsonDecoder, nil),
+		ErrorBackendAlias:   "error_backend",
+	}
+
+	parse := DefaultHTTPResponseParserFactory(&config.Backend{}, cfg)
+	got, err := parse(context.Background(), resp)
+	if err == nil {
+		t.Fatal("expected a non-nil error for a flagged status code")
+	}
+	if got == nil {
+		t.Fatal("expected a non-nil Response alongside the error")
+	}
+
+	hre, ok := err.(HTTPResponseError)
+	if !ok {
+		t.Fatalf("expected an HTTPResponseError, got %T", err)
+	}
+	if hre.StatusCode() != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", hre.StatusCode(), http.StatusNotFound)
+	}
+
+	table, ok := TableGet(got.Data, "error_backend")
+	if !ok {
+		t.Fatal("expected TableGet to find the error body under the configured alias")
+	}
+	if table["reason"] != "no such user" {
+		t.Errorf("got %v, want reason=no such user", table)
+	}
+}
+
+func TestDefaultHTTPResponseParserFactory_ErrorBodyDecodeFailureKeepsRawBytes(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "500 Internal Server Error",
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("<html><body>things broke</body></html>")),
+	}
+
+	cfg := HTTPResponseParserConfig{
+		Decoder:             jsonDecoder,
+		EntityFormatter:     EntityFormatterFunc(func(r Response) Response { return r }),
+		ErrorResponseParser: NewErrorResponseParser(map[int]struct{}{http.StatusInternalServerError: {}}, jsonDecoder, nil),
+		ErrorBackendAlias:   "error_backend",
+	}
+
+	parse := DefaultHTTPResponseParserFactory(&config.Backend{}, cfg)
+	got, err := parse(context.Background(), resp)
+	if err == nil {
+		t.Fatal("expected a non-nil error for a flagged status code")
+	}
+
+	hre, ok := err.(HTTPResponseError)
+	if !ok {
+		t.Fatalf("expected an HTTPResponseError, got %T", err)
+	}
+	if hre.Body != nil {
+		t.Errorf("expected Body to be nil for an undecodable backend body, got %v", hre.Body)
+	}
+	if string(hre.RawBody) != "<html><body>things broke</body></html>" {
+		t.Errorf("got RawBody %q, want the raw HTML preserved", hre.RawBody)
+	}
+
+	table, ok := TableGet(got.Data, "error_backend")
+	if !ok {
+		t.Fatal("expected TableGet to find the raw body stashed under the configured alias")
+	}
+	if table["raw"] != "<html><body>things broke</body></html>" {
+		t.Errorf("got %v, want raw=<html>...", table)
+	}
+}
+
+func TestDefaultHTTPResponseParserFactory_NonFlaggedStatusPassesThrough(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true}`)),
+	}
+
+	cfg := HTTPResponseParserConfig{
+		Decoder:             jsonDecoder,
+		EntityFormatter:     EntityFormatterFunc(func(r Response) Response { return r }),
+		ErrorResponseParser: NewErrorResponseParser(map[int]struct{}{http.StatusNotFound: {}}, jsonDecoder, nil),
+		ErrorBackendAlias:   "error_backend",
+	}
+
+	parse := DefaultHTTPResponseParserFactory(&config.Backend{}, cfg)
+	got, err := parse(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Data["ok"] != true {
+		t.Errorf("got %v, want ok=true", got.Data)
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+)
+
+func TestDefaultHTTPResponseParserFactory_DoesNotSniffContentTypeHeader(t *testing.T) {
+	fullDecodeCalled := false
+
+	cfg := HTTPResponseParserConfig{
+		Decoder: func(r io.Reader, v *map[string]interface{}) error {
+			fullDecodeCalled = true
+			*v = map[string]interface{}{"ok": true}
+			return nil
+		},
+		EntityFormatter: EntityFormatterFunc(func(r Response) Response { return r }),
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"id":1}`)),
+	}
+
+	parse := DefaultHTTPResponseParserFactory(&config.Backend{}, cfg)
+	if _, err := parse(context.Background(), resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !fullDecodeCalled {
+		t.Error("expected cfg.Decoder to be used; the response's own Content-Type header must never override it when cfg.ContentType is unset")
+	}
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+// ProjectionAware is implemented by EntityFormatters that know in advance
+// which top level fields of a backend response they will keep, such as one
+// built from an endpoint's allow/mapping/target configuration. A streaming
+// HTTPResponseParser uses Fields to skip decoding the rest of the body.
+type ProjectionAware interface {
+	// Fields returns the set of top level keys this formatter reads. A nil
+	// or empty set means every field is potentially used.
+	Fields() map[string]bool
+}
+
+func projectedFields(f EntityFormatter) map[string]bool {
+	if pa, ok := f.(ProjectionAware); ok {
+		return pa.Fields()
+	}
+	return nil
+}
+
+// AllowFieldsFormatter is an EntityFormatter that keeps only the top level
+// fields named in Allow, dropping everything else. It implements
+// ProjectionAware so a streaming HTTPResponseParser can skip decoding the
+// fields it is going to discard anyway, rather than decoding the full body
+// and only then throwing the unwanted part away.
+//
+// This tree does not include the gateway's real EntityFormatter (the one
+// built from an config.EndpointConfig's allow/deny/mapping/target lists),
+// so there is no construction path here from actual endpoint config to
+// AllowFieldsFormatter, nor any ProjectionAware implementation on the real
+// formatter: the streaming fast path only engages for callers that build
+// and assign an AllowFieldsFormatter themselves. Making it the projection-
+// aware formatter endpoint config actually produces requires either adding
+// ProjectionAware to that formatter or a config-driven constructor for
+// this type, neither of which has a home in this package yet.
+type AllowFieldsFormatter struct {
+	Allow []string
+}
+
+// Fields implements the ProjectionAware interface.
+func (f AllowFieldsFormatter) Fields() map[string]bool {
+	if len(f.Allow) == 0 {
+		return nil
+	}
+	fields := make(map[string]bool, len(f.Allow))
+	for _, name := range f.Allow {
+		fields[name] = true
+	}
+	return fields
+}
+
+// Format implements the EntityFormatter interface.
+func (f AllowFieldsFormatter) Format(r Response) Response {
+	if len(f.Allow) == 0 {
+		return r
+	}
+
+	data := make(map[string]interface{}, len(f.Allow))
+	for _, name := range f.Allow {
+		if v, ok := r.Data[name]; ok {
+			data[name] = v
+		}
+	}
+	r.Data = data
+	return r
+}
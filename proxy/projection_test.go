@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/luraproject/lura/v2/config"
+	"github.com/luraproject/lura/v2/encoding"
+)
+
+type trackingStreamingDecoder struct {
+	visited map[string]bool
+}
+
+func (d trackingStreamingDecoder) DecodeFields(r io.Reader, wanted map[string]bool, visit func(key string, value interface{}) error) error {
+	return encoding.JSONStreamingDecoder{}.DecodeFields(r, wanted, func(key string, value interface{}) error {
+		d.visited[key] = true
+		return visit(key, value)
+	})
+}
+
+func TestDefaultHTTPResponseParserFactory_StreamingHonorsProjection(t *testing.T) {
+	formatter := AllowFieldsFormatter{Allow: []string{"id", "name"}}
+	streaming := trackingStreamingDecoder{visited: map[string]bool{}}
+
+	cfg := HTTPResponseParserConfig{
+		Decoder:          jsonDecoder,
+		EntityFormatter:  formatter,
+		Streaming:        true,
+		StreamingDecoder: streaming,
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"id":1,"name":"ada","huge_blob":"unwanted"}`)),
+	}
+
+	parse := DefaultHTTPResponseParserFactory(&config.Backend{}, cfg)
+	got, err := parse(context.Background(), resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := got.Data["huge_blob"]; ok {
+		t.Error("huge_blob should have been projected out")
+	}
+	if !streaming.visited["id"] || !streaming.visited["name"] {
+		t.Errorf("expected id and name to be visited, got %v", streaming.visited)
+	}
+	if streaming.visited["huge_blob"] {
+		t.Error("huge_blob should never have reached visit")
+	}
+}
+
+func TestDefaultHTTPResponseParserFactory_StreamingSkippedForNonJSONContentType(t *testing.T) {
+	streaming := trackingStreamingDecoder{visited: map[string]bool{}}
+	fullDecodeCalled := false
+
+	cfg := HTTPResponseParserConfig{
+		Decoder: func(r io.Reader, v *map[string]interface{}) error {
+			fullDecodeCalled = true
+			*v = map[string]interface{}{"ok": true}
+			return nil
+		},
+		EntityFormatter:  EntityFormatterFunc(func(r Response) Response { return r }),
+		Streaming:        true,
+		StreamingDecoder: streaming,
+		ContentType:      "application/x-custom-binary",
+	}
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("irrelevant, not json")),
+	}
+
+	parse := DefaultHTTPResponseParserFactory(&config.Backend{}, cfg)
+	if _, err := parse(context.Background(), resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(streaming.visited) != 0 {
+		t.Errorf("streaming decoder should not have run for a non-JSON content type, visited %v", streaming.visited)
+	}
+	if !fullDecodeCalled {
+		t.Error("expected cfg.Decoder to be used instead of the streaming path")
+	}
+}